@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// migrationChecksum returns the hex-encoded SHA-256 digest of a migration's
+// rendered UpSQL.
+func migrationChecksum(upSQL string) string {
+	sum := sha256.Sum256([]byte(upSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrationChecksumError is returned by Migrator.Verify (and, unless
+// AllowDrift is set, by MigrateTo) when a previously applied migration's
+// file on disk no longer matches the checksum recorded when it was applied.
+type MigrationChecksumError struct {
+	File     string
+	Expected string
+	Actual   string
+}
+
+func (e MigrationChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.File, e.Expected, e.Actual)
+}
+
+// ErrChecksumVerificationUnsupported is returned by Verify when the
+// Migrator's StorageDriver does not implement ChecksumVerifier, so there is
+// nothing to compare against - distinct from a nil error, which means
+// verification ran and found no drift. MigrateTo does not treat it as a
+// failure and proceeds without verification on such a driver.
+var ErrChecksumVerificationUnsupported = errors.New("migrate: checksum verification is not supported by this StorageDriver")
+
+// Verify compares the checksum of every loaded migration that has already
+// been applied against the checksum recorded at the time it was applied,
+// returning a MigrationChecksumError on the first mismatch found. If the
+// underlying StorageDriver does not implement ChecksumVerifier, Verify
+// returns ErrChecksumVerificationUnsupported rather than silently reporting
+// no drift.
+//
+// MigrateTo calls Verify automatically before applying any migrations,
+// unless MigratorOptions.AllowDrift is set, and treats
+// ErrChecksumVerificationUnsupported as nothing to verify rather than
+// aborting, since that error means the driver (e.g. ClickHouseDriver) has no
+// checksum storage at all rather than that drift was found.
+func (m *Migrator) Verify(ctx context.Context) error {
+	verifier, ok := m.driver.(ChecksumVerifier)
+	if !ok {
+		return ErrChecksumVerificationUnsupported
+	}
+
+	checksums, err := verifier.GetAppliedChecksums(ctx, m.versionTable)
+	if err != nil {
+		return err
+	}
+
+	for _, mig := range m.Migrations {
+		expected, ok := checksums[mig.ID]
+		if !ok {
+			continue
+		}
+		if expected != mig.Checksum {
+			return MigrationChecksumError{File: mig.Name, Expected: expected, Actual: mig.Checksum}
+		}
+	}
+
+	return nil
+}
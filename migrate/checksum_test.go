@@ -0,0 +1,20 @@
+package migrate
+
+import "testing"
+
+func TestMigrationChecksum(t *testing.T) {
+	a := migrationChecksum("create table widgets(id int);")
+	b := migrationChecksum("create table widgets(id int);")
+	if a != b {
+		t.Fatalf("checksum of identical SQL differed: %s != %s", a, b)
+	}
+
+	c := migrationChecksum("create table gadgets(id int);")
+	if a == c {
+		t.Fatalf("checksum of different SQL matched: %s", a)
+	}
+
+	if len(a) != 64 {
+		t.Fatalf("got checksum length %d, want 64 (hex-encoded SHA-256)", len(a))
+	}
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+)
+
+// StorageDriver abstracts the database-specific operations a Migrator needs
+// in order to track and apply migrations, so the rest of the migration
+// layer (file discovery, templates, sprig functions) stays database
+// agnostic.
+type StorageDriver interface {
+	// EnsureVersionTable creates the version table if it does not already
+	// exist, seeding it with version 0.
+	EnsureVersionTable(ctx context.Context, versionTable string) error
+	// GetCurrentVersion returns the version currently recorded in the
+	// version table.
+	GetCurrentVersion(ctx context.Context, versionTable string) (int32, error)
+	// SetVersion records version in the version table. tx is whatever was
+	// returned by BeginTx for the migration currently being applied, or nil
+	// if DisableTx is set.
+	SetVersion(ctx context.Context, tx DriverTx, versionTable string, version int32) error
+	// Lock acquires a database-wide lock so that only one Migrator can run
+	// migrations against versionTable at a time. It must be safe to call
+	// even if the version table does not exist yet.
+	Lock(ctx context.Context) error
+	// Unlock releases the lock acquired by Lock.
+	Unlock(ctx context.Context) error
+	// BeginTx starts a transaction that Exec and SetVersion will run in. If
+	// the driver has no meaningful transaction support it may return a
+	// no-op DriverTx.
+	BeginTx(ctx context.Context) (DriverTx, error)
+	// Exec executes a single migration step's SQL, using tx if non-nil.
+	// name is the migration file name, used to annotate errors.
+	Exec(ctx context.Context, tx DriverTx, name, sql string) error
+}
+
+// DriverTx is the subset of transaction behavior a StorageDriver needs to
+// expose to the Migrator. It is satisfied by pgx.Tx and by thin wrappers
+// around *sql.Tx for database/sql based drivers.
+type DriverTx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// AppliedMigrationsRecorder is implemented by drivers that additionally
+// maintain an applied_migrations(id bigint primary key, name text, checksum
+// text, applied_at timestamptz) log alongside the version table, which is
+// what lets Migrator.Verify detect drift later. id is a primary key:
+// RecordAppliedMigration upserts rather than inserting a duplicate row, and
+// RemoveAppliedMigration is called on revert so a reverted migration's
+// stale checksum can't cause a false drift report later.
+type AppliedMigrationsRecorder interface {
+	RecordAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64, name, checksum string) error
+	RemoveAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64) error
+}
+
+// ChecksumVerifier is implemented by drivers that can report back the
+// checksum recorded for each applied migration, keyed by migration ID, so
+// Migrator.Verify can detect drift between what was applied and what is
+// currently on disk.
+type ChecksumVerifier interface {
+	GetAppliedChecksums(ctx context.Context, versionTable string) (map[int64]string, error)
+}
+
+// AppliedMigrationsRenumberer is implemented by drivers that can change the
+// id of an already-applied migration's row in the applied_migrations log.
+// Migrator.Fix calls it to keep that log in step when it renumbers
+// TimestampIDs migration files on disk.
+type AppliedMigrationsRenumberer interface {
+	RenumberAppliedMigration(ctx context.Context, versionTable string, oldID, newID int64) error
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ClickHouseDriver is the StorageDriver implementation for ClickHouse, using
+// database/sql and the ClickHouse/clickhouse-go driver.
+//
+// ClickHouse has no cross-connection advisory lock primitive, so Lock/Unlock
+// are no-ops; coordinating concurrent migration runs across processes is the
+// caller's responsibility.
+//
+// ClickHouseDriver does not implement AppliedMigrationsRecorder or
+// ChecksumVerifier: an applied_migrations log needs per-row upsert and
+// delete, neither of which TinyLog/MergeTree support cheaply. Migrator.Verify
+// returns ErrChecksumVerificationUnsupported for this driver, which MigrateTo
+// treats as nothing to verify rather than an error, so a ClickHouse migrator
+// works out of the box without MigratorOptions.AllowDrift.
+type ClickHouseDriver struct {
+	sqlDriver
+}
+
+// NewClickHouseDriver wraps db as a StorageDriver.
+func NewClickHouseDriver(db *sql.DB) *ClickHouseDriver {
+	return &ClickHouseDriver{sqlDriver{db: db}}
+}
+
+func (d *ClickHouseDriver) Lock(ctx context.Context) error {
+	return nil
+}
+
+func (d *ClickHouseDriver) Unlock(ctx context.Context) error {
+	return nil
+}
+
+func (d *ClickHouseDriver) EnsureVersionTable(ctx context.Context, versionTable string) error {
+	// TinyLog has no UPDATE support at all, so the version is tracked as an
+	// append-only log of rows instead of a single row that gets updated in
+	// place: SetVersion inserts, GetCurrentVersion reads back the version
+	// from the most recently inserted row. That can't be max(version) -
+	// max would report the pre-revert version forever after a down
+	// migration inserts a lower version number, causing it to be
+	// re-reverted on every subsequent MigrateTo - nor can it be
+	// applied_at alone, since two inserts can land in the same
+	// microsecond (fast consecutive migrations, or a down then up back to
+	// the same version) and make ordering ambiguous. seq is an explicit,
+	// append-ordered counter SetVersion increments itself, giving a
+	// deterministic tiebreaker independent of wall-clock resolution.
+	//
+	// clickhouse-go does not support semicolon-separated multi-statement
+	// Exec calls at all, so table creation and seeding are two statements.
+	_, err := d.db.ExecContext(ctx, fmt.Sprintf("create table if not exists %s(version Int32, seq Int64) engine=TinyLog", versionTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, fmt.Sprintf(`
+    insert into %s(version, seq)
+    select 0, 0
+    where 0=(select count(*) from %s)
+  `, versionTable, versionTable))
+	return err
+}
+
+func (d *ClickHouseDriver) GetCurrentVersion(ctx context.Context, versionTable string) (v int32, err error) {
+	err = d.db.QueryRowContext(ctx, "select version from "+versionTable+" order by seq desc limit 1").Scan(&v)
+	return v, err
+}
+
+func (d *ClickHouseDriver) SetVersion(ctx context.Context, tx DriverTx, versionTable string, version int32) error {
+	query := fmt.Sprintf(`
+    insert into %s(version, seq)
+    select ?, coalesce(max(seq), 0) + 1 from %s
+  `, versionTable, versionTable)
+	if stx, ok := tx.(sqlTx); ok {
+		_, err := stx.tx.ExecContext(ctx, query, version)
+		return err
+	}
+	_, err := d.db.ExecContext(ctx, query, version)
+	return err
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// mysqlLockName is the named lock used by GET_LOCK/RELEASE_LOCK to serialize
+// migration runs against a given database.
+const mysqlLockName = "tern_migrate"
+
+// MySQLDriver is the StorageDriver implementation for MySQL, using
+// database/sql and the go-sql-driver/mysql driver.
+type MySQLDriver struct {
+	sqlDriver
+	// lockConn is the single connection GET_LOCK was acquired on. GET_LOCK
+	// is scoped to the session that called it, so Lock and Unlock - and
+	// nothing else - must run on this same *sql.Conn rather than whatever
+	// connection database/sql's pool would otherwise hand out.
+	lockConn *sql.Conn
+}
+
+// NewMySQLDriver wraps db as a StorageDriver.
+func NewMySQLDriver(db *sql.DB) *MySQLDriver {
+	return &MySQLDriver{sqlDriver: sqlDriver{db: db}}
+}
+
+func (d *MySQLDriver) Lock(ctx context.Context) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var got int
+	if err := conn.QueryRowContext(ctx, "select get_lock(?, -1)", mysqlLockName).Scan(&got); err != nil {
+		conn.Close()
+		return err
+	}
+
+	d.lockConn = conn
+	return nil
+}
+
+func (d *MySQLDriver) Unlock(ctx context.Context) error {
+	if d.lockConn == nil {
+		return nil
+	}
+	conn := d.lockConn
+	d.lockConn = nil
+
+	_, err := conn.ExecContext(ctx, "select release_lock(?)", mysqlLockName)
+	if closeErr := conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *MySQLDriver) EnsureVersionTable(ctx context.Context, versionTable string) (err error) {
+	err = d.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		unlockErr := d.Unlock(ctx)
+		if err == nil && unlockErr != nil {
+			err = unlockErr
+		}
+	}()
+
+	// go-sql-driver/mysql only executes multiple semicolon-separated
+	// statements in one Exec when the DSN opts into multiStatements, which
+	// is off by default, so these run as two separate statements.
+	_, err = d.db.ExecContext(ctx, fmt.Sprintf("create table if not exists %s(version int not null)", versionTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, fmt.Sprintf(`
+    insert into %s(version)
+    select 0 from dual
+    where 0=(select count(*) from %s)
+  `, versionTable, versionTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, fmt.Sprintf(
+		"create table if not exists %s(id bigint primary key, name text not null, checksum text not null, applied_at timestamp not null default current_timestamp)",
+		appliedMigrationsTable(versionTable)))
+	return err
+}
+
+// RecordAppliedMigration implements AppliedMigrationsRecorder.
+func (d *MySQLDriver) RecordAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64, name, checksum string) error {
+	exec := d.db.ExecContext
+	if stx, ok := tx.(sqlTx); ok {
+		exec = stx.tx.ExecContext
+	}
+	_, err := exec(ctx, `
+    insert into `+appliedMigrationsTable(versionTable)+`(id, name, checksum) values (?, ?, ?)
+    on duplicate key update name = values(name), checksum = values(checksum), applied_at = current_timestamp
+  `, id, name, checksum)
+	return err
+}
+
+// RemoveAppliedMigration implements AppliedMigrationsRecorder.
+func (d *MySQLDriver) RemoveAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64) error {
+	exec := d.db.ExecContext
+	if stx, ok := tx.(sqlTx); ok {
+		exec = stx.tx.ExecContext
+	}
+	_, err := exec(ctx, "delete from "+appliedMigrationsTable(versionTable)+" where id = ?", id)
+	return err
+}
+
+// GetAppliedChecksums implements ChecksumVerifier.
+func (d *MySQLDriver) GetAppliedChecksums(ctx context.Context, versionTable string) (map[int64]string, error) {
+	rows, err := d.db.QueryContext(ctx, "select id, checksum from "+appliedMigrationsTable(versionTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var checksum string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[id] = checksum
+	}
+	return checksums, rows.Err()
+}
+
+// RenumberAppliedMigration implements AppliedMigrationsRenumberer.
+func (d *MySQLDriver) RenumberAppliedMigration(ctx context.Context, versionTable string, oldID, newID int64) error {
+	_, err := d.db.ExecContext(ctx, "update "+appliedMigrationsTable(versionTable)+" set id = ? where id = ?", newID, oldID)
+	return err
+}
@@ -0,0 +1,176 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// postgresLockNum is the key used for pg_advisory_lock. It is arbitrary, but
+// fixed so that every Migrator instance contends for the same lock.
+const postgresLockNum = int64(9628173550095224)
+
+// PostgresDriver is the StorageDriver implementation for Postgres, using
+// pgx. It is the default driver used by NewMigrator.
+type PostgresDriver struct {
+	conn *pgx.Conn
+}
+
+// NewPostgresDriver wraps conn as a StorageDriver.
+func NewPostgresDriver(conn *pgx.Conn) *PostgresDriver {
+	return &PostgresDriver{conn: conn}
+}
+
+// Conn returns the underlying *pgx.Conn, for callers that need Postgres
+// specific behavior (such as Go migrations) beyond the StorageDriver
+// interface.
+func (d *PostgresDriver) Conn() *pgx.Conn {
+	return d.conn
+}
+
+func (d *PostgresDriver) Lock(ctx context.Context) error {
+	_, err := d.conn.Exec(ctx, "select pg_advisory_lock($1)", postgresLockNum)
+	return err
+}
+
+func (d *PostgresDriver) Unlock(ctx context.Context) error {
+	_, err := d.conn.Exec(ctx, "select pg_advisory_unlock($1)", postgresLockNum)
+	return err
+}
+
+func (d *PostgresDriver) EnsureVersionTable(ctx context.Context, versionTable string) (err error) {
+	err = d.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		unlockErr := d.Unlock(ctx)
+		if err == nil && unlockErr != nil {
+			err = unlockErr
+		}
+	}()
+
+	if ok, err := d.versionTableExists(ctx, versionTable); err != nil || !ok {
+		if err != nil {
+			return err
+		}
+
+		_, err = d.conn.Exec(ctx, fmt.Sprintf(`
+      create table if not exists %s(version int4 not null);
+
+      insert into %s(version)
+      select 0
+      where 0=(select count(*) from %s);
+    `, versionTable, versionTable, versionTable))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Created unconditionally, even when versionTable already exists: a
+	// database migrated by a tern version from before chunk0-3 has a
+	// version table but no applied_migrations table yet, and Verify/
+	// RecordAppliedMigration must not 404 against it on the next run.
+	_, err = d.conn.Exec(ctx, fmt.Sprintf(`
+    create table if not exists %s(id bigint primary key, name text not null, checksum text not null, applied_at timestamptz not null default now());
+  `, appliedMigrationsTable(versionTable)))
+	return err
+}
+
+// appliedMigrationsTable derives the name of the applied_migrations log
+// table from versionTable, preserving its schema qualification if any.
+func appliedMigrationsTable(versionTable string) string {
+	return versionTable + "_applied_migrations"
+}
+
+// RecordAppliedMigration implements AppliedMigrationsRecorder. id is the
+// table's primary key, so re-applying a migration (up, down, then up again)
+// overwrites its previous row rather than accumulating duplicates.
+func (d *PostgresDriver) RecordAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64, name, checksum string) error {
+	_, err := d.conn.Exec(ctx, `
+    insert into `+appliedMigrationsTable(versionTable)+`(id, name, checksum) values ($1, $2, $3)
+    on conflict (id) do update set name = excluded.name, checksum = excluded.checksum, applied_at = excluded.applied_at
+  `, id, name, checksum)
+	return err
+}
+
+// RemoveAppliedMigration implements AppliedMigrationsRecorder. It is called
+// when a migration is reverted, so a stale checksum for a migration that is
+// no longer part of the current schema can't later cause a false-positive
+// MigrationChecksumError if the reverted file is edited while unapplied.
+func (d *PostgresDriver) RemoveAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64) error {
+	_, err := d.conn.Exec(ctx, "delete from "+appliedMigrationsTable(versionTable)+" where id = $1", id)
+	return err
+}
+
+// RenumberAppliedMigration implements AppliedMigrationsRenumberer.
+func (d *PostgresDriver) RenumberAppliedMigration(ctx context.Context, versionTable string, oldID, newID int64) error {
+	_, err := d.conn.Exec(ctx, "update "+appliedMigrationsTable(versionTable)+" set id = $2 where id = $1", oldID, newID)
+	return err
+}
+
+// GetAppliedChecksums implements ChecksumVerifier.
+func (d *PostgresDriver) GetAppliedChecksums(ctx context.Context, versionTable string) (map[int64]string, error) {
+	rows, err := d.conn.Query(ctx, "select id, checksum from "+appliedMigrationsTable(versionTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// id is the table's primary key, so there is at most one row per id and
+	// no ordering is needed to make this deterministic.
+	checksums := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var checksum string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[id] = checksum
+	}
+	return checksums, rows.Err()
+}
+
+func (d *PostgresDriver) versionTableExists(ctx context.Context, versionTable string) (ok bool, err error) {
+	var count int
+	if i := strings.IndexByte(versionTable, '.'); i == -1 {
+		err = d.conn.QueryRow(ctx, "select count(*) from pg_catalog.pg_class where relname=$1 and relkind='r' and pg_table_is_visible(oid)", versionTable).Scan(&count)
+	} else {
+		schema, table := versionTable[:i], versionTable[i+1:]
+		err = d.conn.QueryRow(ctx, "select count(*) from pg_catalog.pg_tables where schemaname=$1 and tablename=$2", schema, table).Scan(&count)
+	}
+	return count > 0, err
+}
+
+func (d *PostgresDriver) GetCurrentVersion(ctx context.Context, versionTable string) (v int32, err error) {
+	err = d.conn.QueryRow(ctx, "select version from "+versionTable).Scan(&v)
+	return v, err
+}
+
+func (d *PostgresDriver) SetVersion(ctx context.Context, tx DriverTx, versionTable string, version int32) error {
+	_, err := d.conn.Exec(ctx, "update "+versionTable+" set version=$1", version)
+	return err
+}
+
+func (d *PostgresDriver) BeginTx(ctx context.Context) (DriverTx, error) {
+	return d.conn.Begin(ctx)
+}
+
+func (d *PostgresDriver) Exec(ctx context.Context, tx DriverTx, name, sql string) error {
+	_, err := d.conn.Exec(ctx, sql)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok {
+			return MigrationPgError{MigrationName: name, Sql: sql, PgError: pgErr}
+		}
+		return err
+	}
+
+	// Reset all database connection settings. Important to do before
+	// updating version as search_path may have been changed.
+	d.conn.Exec(ctx, "reset all")
+
+	return nil
+}
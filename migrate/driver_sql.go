@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sqlTx adapts *sql.Tx to the DriverTx interface.
+type sqlTx struct {
+	tx *sql.Tx
+}
+
+func (t sqlTx) Commit(ctx context.Context) error {
+	return t.tx.Commit()
+}
+
+func (t sqlTx) Rollback(ctx context.Context) error {
+	return t.tx.Rollback()
+}
+
+// sqlDriver implements the parts of StorageDriver that are identical across
+// database/sql based backends (MySQL, SQLite, ClickHouse). Dialect
+// differences (locking and the version table DDL) are supplied by the
+// embedding driver.
+type sqlDriver struct {
+	db *sql.DB
+}
+
+func (d sqlDriver) GetCurrentVersion(ctx context.Context, versionTable string) (v int32, err error) {
+	err = d.db.QueryRowContext(ctx, "select version from "+versionTable).Scan(&v)
+	return v, err
+}
+
+func (d sqlDriver) SetVersion(ctx context.Context, tx DriverTx, versionTable string, version int32) error {
+	stx, ok := tx.(sqlTx)
+	if !ok {
+		_, err := d.db.ExecContext(ctx, "update "+versionTable+" set version=?", version)
+		return err
+	}
+	_, err := stx.tx.ExecContext(ctx, "update "+versionTable+" set version=?", version)
+	return err
+}
+
+func (d sqlDriver) BeginTx(ctx context.Context) (DriverTx, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return sqlTx{tx: tx}, nil
+}
+
+func (d sqlDriver) Exec(ctx context.Context, tx DriverTx, name, sql string) error {
+	var err error
+	if stx, ok := tx.(sqlTx); ok {
+		_, err = stx.tx.ExecContext(ctx, sql)
+	} else {
+		_, err = d.db.ExecContext(ctx, sql)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SQLiteDriver is the StorageDriver implementation for SQLite, using
+// database/sql and the mattn/go-sqlite3 driver.
+//
+// SQLite has no server-side advisory lock, so Lock/Unlock are no-ops;
+// cross-process coordination is left to the caller (e.g. a file lock).
+type SQLiteDriver struct {
+	sqlDriver
+}
+
+// NewSQLiteDriver wraps db as a StorageDriver.
+func NewSQLiteDriver(db *sql.DB) *SQLiteDriver {
+	return &SQLiteDriver{sqlDriver{db: db}}
+}
+
+func (d *SQLiteDriver) Lock(ctx context.Context) error {
+	return nil
+}
+
+func (d *SQLiteDriver) Unlock(ctx context.Context) error {
+	return nil
+}
+
+func (d *SQLiteDriver) EnsureVersionTable(ctx context.Context, versionTable string) error {
+	_, err := d.db.ExecContext(ctx, fmt.Sprintf(`
+    create table if not exists %s(version integer not null);
+
+    insert into %s(version)
+    select 0
+    where 0=(select count(*) from %s);
+  `, versionTable, versionTable, versionTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.ExecContext(ctx, fmt.Sprintf(
+		"create table if not exists %s(id integer primary key, name text not null, checksum text not null, applied_at timestamp not null default current_timestamp)",
+		appliedMigrationsTable(versionTable)))
+	return err
+}
+
+// RecordAppliedMigration implements AppliedMigrationsRecorder.
+func (d *SQLiteDriver) RecordAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64, name, checksum string) error {
+	exec := d.db.ExecContext
+	if stx, ok := tx.(sqlTx); ok {
+		exec = stx.tx.ExecContext
+	}
+	_, err := exec(ctx, `
+    insert into `+appliedMigrationsTable(versionTable)+`(id, name, checksum) values (?, ?, ?)
+    on conflict(id) do update set name = excluded.name, checksum = excluded.checksum, applied_at = excluded.applied_at
+  `, id, name, checksum)
+	return err
+}
+
+// RemoveAppliedMigration implements AppliedMigrationsRecorder.
+func (d *SQLiteDriver) RemoveAppliedMigration(ctx context.Context, tx DriverTx, versionTable string, id int64) error {
+	exec := d.db.ExecContext
+	if stx, ok := tx.(sqlTx); ok {
+		exec = stx.tx.ExecContext
+	}
+	_, err := exec(ctx, "delete from "+appliedMigrationsTable(versionTable)+" where id = ?", id)
+	return err
+}
+
+// GetAppliedChecksums implements ChecksumVerifier.
+func (d *SQLiteDriver) GetAppliedChecksums(ctx context.Context, versionTable string) (map[int64]string, error) {
+	rows, err := d.db.QueryContext(ctx, "select id, checksum from "+appliedMigrationsTable(versionTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var checksum string
+		if err := rows.Scan(&id, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[id] = checksum
+	}
+	return checksums, rows.Err()
+}
+
+// RenumberAppliedMigration implements AppliedMigrationsRenumberer.
+func (d *SQLiteDriver) RenumberAppliedMigration(ctx context.Context, versionTable string, oldID, newID int64) error {
+	_, err := d.db.ExecContext(ctx, "update "+appliedMigrationsTable(versionTable)+" set id = ? where id = ?", newID, oldID)
+	return err
+}
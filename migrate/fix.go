@@ -0,0 +1,114 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fixRename describes renumbering a single TimestampIDs migration file, both
+// on disk and (via its oldID/newID) in an applied_migrations log.
+type fixRename struct {
+	oldID, newID     int64
+	fromPath, toPath string
+}
+
+// planFix computes the renumbering FindTimestampedMigrationsEx's files under
+// path need in order to become a contiguous 1..N sequence, in the
+// lexicographic order they would be loaded in. It touches neither the
+// filesystem nor a database, which makes it the testable core of Fix.
+func planFix(path string) ([]fixRename, error) {
+	paths, err := FindTimestampedMigrations(path)
+	if err != nil {
+		return nil, err
+	}
+
+	renames := make([]fixRename, 0, len(paths))
+	for i, p := range paths {
+		name := filepath.Base(p)
+		matches := timestampMigrationPattern.FindStringSubmatch(name)
+		oldID, err := parseTimestampID(name)
+		if err != nil {
+			return nil, err
+		}
+
+		newID := int64(i + 1)
+		suffix := strings.TrimPrefix(name, matches[1])
+		newName := fmt.Sprintf("%05d%s", newID, suffix)
+		if newName == name {
+			continue
+		}
+
+		renames = append(renames, fixRename{
+			oldID:    oldID,
+			newID:    newID,
+			fromPath: p,
+			toPath:   filepath.Join(path, newName),
+		})
+	}
+
+	return renames, nil
+}
+
+// Fix renumbers timestamp-prefixed migration files under path (such as
+// 20240115093000_add_users.sql) into a contiguous sequence starting at 1, in
+// the lexicographic order FindTimestampedMigrationsEx would load them. File
+// suffixes (the part after the timestamp) and contents are left untouched.
+// It is meant to be run once a feature branch carrying timestamped
+// migrations has been merged, mirroring goose's `fix` command, so the
+// directory goes back to looking like a normal sequentially numbered one.
+//
+// If m's StorageDriver implements AppliedMigrationsRenumberer, already
+// applied migrations' rows in the applied_migrations log are renumbered to
+// match, so Migrator.Verify keeps matching them up by checksum afterwards.
+// Renumbering goes through a high offset first so a new ID can never
+// collide with an old ID still awaiting its own rename.
+func (m *Migrator) Fix(ctx context.Context, path string) error {
+	renames, err := planFix(path)
+	if err != nil {
+		return err
+	}
+
+	if renumberer, ok := m.driver.(AppliedMigrationsRenumberer); ok {
+		const renumberOffset = int64(1) << 40
+		for _, r := range renames {
+			if err := renumberer.RenumberAppliedMigration(ctx, m.versionTable, r.oldID, r.oldID+renumberOffset); err != nil {
+				return err
+			}
+		}
+		for _, r := range renames {
+			if err := renumberer.RenumberAppliedMigration(ctx, m.versionTable, r.oldID+renumberOffset, r.newID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, r := range renames {
+		if err := os.Rename(r.fromPath, r.toPath); err != nil {
+			return fmt.Errorf("fix: renaming %s to %s: %w", r.fromPath, r.toPath, err)
+		}
+	}
+
+	return nil
+}
+
+// Fix renumbers timestamp-prefixed migration files under path the same way
+// Migrator.Fix does, but operates purely on the filesystem: it has no driver
+// to renumber an applied_migrations log with. Prefer Migrator.Fix when an
+// applied_migrations log needs to stay in sync.
+func Fix(path string) error {
+	renames, err := planFix(path)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range renames {
+		if err := os.Rename(r.fromPath, r.toPath); err != nil {
+			return fmt.Errorf("fix: renaming %s to %s: %w", r.fromPath, r.toPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanFix(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"20240115093000_add_users.sql",
+		"20240101000000_create_widgets.sql",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("-- up"), 0644); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+
+	renames, err := planFix(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 20240101000000_create_widgets.sql sorts first and becomes 00001,
+	// 20240115093000_add_users.sql becomes 00002.
+	if len(renames) != 2 {
+		t.Fatalf("got %d renames, want 2: %+v", len(renames), renames)
+	}
+
+	if got, want := filepath.Base(renames[0].toPath), "00001_create_widgets.sql"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if got, want := renames[0].oldID, int64(20240101000000); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+	if got, want := renames[0].newID, int64(1); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+
+	if got, want := filepath.Base(renames[1].toPath), "00002_add_users.sql"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
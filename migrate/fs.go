@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"context"
+	"io/fs"
+	"os"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// FSMigratorFS adapts any fs.FS - most notably an embed.FS produced by
+// //go:embed - to the MigratorFS interface.
+type FSMigratorFS struct {
+	FS fs.FS
+}
+
+func (f FSMigratorFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(f.FS, dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, len(entries))
+	for i, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
+
+func (f FSMigratorFS) ReadFile(filename string) ([]byte, error) {
+	return fs.ReadFile(f.FS, filename)
+}
+
+func (f FSMigratorFS) Glob(pattern string) ([]string, error) {
+	return fs.Glob(f.FS, pattern)
+}
+
+// NewMigratorFromFS initializes a new Migrator targeting Postgres via conn,
+// reading migrations (and any snapshots) from root within fsys instead of
+// the real filesystem, and loads them immediately. It is highly
+// recommended that versionTable be schema qualified. fsys paths are always
+// "/"-separated, per io/fs.
+func NewMigratorFromFS(ctx context.Context, conn *pgx.Conn, versionTable string, fsys fs.FS, root string) (m *Migrator, err error) {
+	m, err = NewMigratorEx(ctx, conn, versionTable, &MigratorOptions{MigratorFS: FSMigratorFS{FS: fsys}})
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.LoadMigrations(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
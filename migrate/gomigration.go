@@ -0,0 +1,163 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"text/template"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// GoMigrationFunc is the signature used by Go-code migrations registered
+// with Register. It receives the transaction the equivalent SQL migration
+// would have run in, so it can execute further SQL, stream rows, or call
+// out to other Go libraries as part of the same migration.
+type GoMigrationFunc func(ctx context.Context, tx pgx.Tx) error
+
+type goMigration struct {
+	sequence int32
+	name     string
+	up, down GoMigrationFunc
+}
+
+// GoMigrationRegistry holds Go-code migrations registered with Register (or
+// with its own Register method). A Migrator only merges them into
+// LoadMigrations when one is explicitly set via MigratorOptions.GoMigrations.
+type GoMigrationRegistry struct {
+	migrations map[int32]*goMigration
+}
+
+// NewGoMigrationRegistry returns an empty GoMigrationRegistry.
+func NewGoMigrationRegistry() *GoMigrationRegistry {
+	return &GoMigrationRegistry{migrations: make(map[int32]*goMigration)}
+}
+
+// Register registers a Go-code migration at sequence in r, to be merged
+// with on-disk SQL migrations by a Migrator configured with
+// MigratorOptions.GoMigrations set to r. The combined set of SQL files and
+// registered Go migrations must together form a contiguous 1..N sequence.
+func (r *GoMigrationRegistry) Register(sequence int32, name string, up, down GoMigrationFunc) error {
+	if _, ok := r.migrations[sequence]; ok {
+		return fmt.Errorf("migrate: Go migration %d already registered", sequence)
+	}
+	r.migrations[sequence] = &goMigration{sequence: sequence, name: name, up: up, down: down}
+	return nil
+}
+
+// DefaultGoMigrations is the registry the package-level Register function
+// populates. Pass it as MigratorOptions.GoMigrations to opt a particular
+// Migrator into merging migrations registered that way.
+var DefaultGoMigrations = NewGoMigrationRegistry()
+
+// Register registers a Go-code migration in DefaultGoMigrations. It is
+// meant to be called from an init() function, before any Migrator loads
+// migrations. See GoMigrationRegistry.Register and
+// MigratorOptions.GoMigrations.
+func Register(sequence int32, name string, up, down GoMigrationFunc) error {
+	return DefaultGoMigrations.Register(sequence, name, up, down)
+}
+
+// findSQLMigrationFiles is like FindMigrationsEx, but does not require the
+// files found to form a contiguous sequence on their own - the gaps may be
+// filled by registered Go migrations. Sequence numbers repeated across
+// files are still rejected.
+func findSQLMigrationFiles(path string, fs MigratorFS) (map[int32]string, error) {
+	fileInfos, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[int32]string)
+	for _, fi := range fileInfos {
+		if fi.IsDir() {
+			continue
+		}
+
+		matches := migrationPattern.FindStringSubmatch(fi.Name())
+		if len(matches) != 2 {
+			continue
+		}
+
+		n, err := strconv.ParseInt(matches[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		seq := int32(n)
+		if _, ok := files[seq]; ok {
+			return nil, fmt.Errorf("Duplicate migration %d", seq)
+		}
+		files[seq] = filepath.Join(path, fi.Name())
+	}
+
+	return files, nil
+}
+
+// execGoMigration invokes mig's Go migration function inside tx, which must
+// be the pgx.Tx returned by a PostgresDriver's BeginTx - Go migrations are,
+// like the on-disk advisory lock they run under, a Postgres-specific
+// feature. They are also incompatible with MigratorOptions.DisableTx, which
+// leaves tx nil: GoMigrationFunc's signature requires a pgx.Tx to run
+// against, and there is no meaningful way to run one outside a transaction.
+func (m *Migrator) execGoMigration(ctx context.Context, tx DriverTx, mig *Migration, up bool) error {
+	if tx == nil {
+		return fmt.Errorf("migrate: Go migration %s cannot run with MigratorOptions.DisableTx set", mig.Name)
+	}
+
+	pgTx, ok := tx.(pgx.Tx)
+	if !ok {
+		return fmt.Errorf("migrate: Go migration %s requires a transaction on a Postgres StorageDriver", mig.Name)
+	}
+
+	fn := mig.DownFn
+	if up {
+		fn = mig.UpFn
+	}
+	if fn == nil {
+		return fmt.Errorf("migrate: Go migration %s has no function for this direction", mig.Name)
+	}
+
+	return fn(ctx, pgTx)
+}
+
+// loadMigrationsWithGo is LoadMigrations' path for when options.GoMigrations
+// is set: SQL files and the registry's Go migrations are merged by sequence
+// number and must together form a contiguous 1..N sequence.
+func (m *Migrator) loadMigrationsWithGo(path string, mainTmpl *template.Template, registry *GoMigrationRegistry) error {
+	sqlFiles, err := findSQLMigrationFiles(path, m.options.MigratorFS)
+	if err != nil {
+		return err
+	}
+
+	total := len(sqlFiles) + len(registry.migrations)
+	if total == 0 {
+		return NoMigrationsFoundError{Path: path}
+	}
+
+	for seq := range registry.migrations {
+		if _, ok := sqlFiles[seq]; ok {
+			return fmt.Errorf("migrate: sequence %d has both a SQL migration file and a registered Go migration", seq)
+		}
+	}
+
+	for i := int32(1); i <= int32(total); i++ {
+		if p, ok := sqlFiles[i]; ok {
+			name, upSQL, downSQL, err := m.loadSQLMigrationFile(mainTmpl, p)
+			if err != nil {
+				return err
+			}
+			m.AppendMigration(name, upSQL, downSQL)
+			continue
+		}
+
+		gm, ok := registry.migrations[i]
+		if !ok {
+			return fmt.Errorf("Missing migration %d", i)
+		}
+		m.appendGoMigration(gm.name, gm.up, gm.down)
+	}
+
+	return nil
+}
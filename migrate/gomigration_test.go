@@ -0,0 +1,19 @@
+package migrate
+
+import "testing"
+
+func TestGoMigrationRegistryRegister(t *testing.T) {
+	r := NewGoMigrationRegistry()
+
+	if err := r.Register(1, "add_users", nil, nil); err != nil {
+		t.Fatalf("unexpected error registering sequence 1: %v", err)
+	}
+
+	if err := r.Register(1, "add_users_again", nil, nil); err == nil {
+		t.Fatal("expected an error re-registering an already-used sequence")
+	}
+
+	if err := r.Register(2, "add_widgets", nil, nil); err != nil {
+		t.Fatalf("unexpected error registering sequence 2: %v", err)
+	}
+}
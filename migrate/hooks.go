@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// Direction identifies whether a migration is being applied ("up") or
+// reverted ("down").
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// Hooks lets callers observe, and for the Before* hooks control, a
+// MigrateTo run in more structured ways than OnStart allows.
+type Hooks struct {
+	// BeforeAll is called once, before any migration in a MigrateTo run is
+	// applied, and before that first migration's transaction is opened.
+	// Returning an error stops the run before any migration is attempted.
+	BeforeAll func(ctx context.Context) error
+	// AfterAll is called once, after every migration in a MigrateTo run has
+	// been applied successfully.
+	AfterAll func(ctx context.Context) error
+	// BeforeMigration is called immediately before a migration's SQL is
+	// executed, inside that migration's transaction (unless DisableTx is
+	// set). Returning an error aborts the run and rolls the transaction
+	// back.
+	BeforeMigration func(ctx context.Context, m *Migration, direction Direction) error
+	// AfterMigration is called after a migration's transaction has
+	// committed (or, with DisableTx, after its version update), with the
+	// time the migration - including that commit - took.
+	AfterMigration func(ctx context.Context, m *Migration, direction Direction, duration time.Duration) error
+	// OnError is called whenever a migration fails. For a failure in
+	// BeforeMigration or the migration's own SQL, the transaction guarding
+	// the migration is still open when it fires; it is rolled back when
+	// MigrateTo returns. For a failure
+	// in AfterMigration, the transaction has already committed, so the
+	// migration is applied even though OnError fires.
+	OnError func(ctx context.Context, m *Migration, err error)
+}
@@ -12,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Masterminds/sprig"
 	"github.com/jackc/pgconn"
@@ -63,36 +64,96 @@ func (e MigrationPgError) Unwrap() error {
 
 type Migration struct {
 	Sequence int32
-	Name     string
-	UpSQL    string
-	DownSQL  string
+	// ID is the durable identifier recorded for this migration. In the
+	// default sequential mode it is equal to Sequence. In TimestampIDs mode
+	// it is the numeric timestamp prefix of the migration's file name, so
+	// migrations written on concurrent feature branches keep stable IDs
+	// once merged, even though their Sequence (position in this run) may
+	// differ from run to run until Fix is used to renumber them.
+	ID      int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	// Checksum is the hex-encoded SHA-256 digest of UpSQL, taken after
+	// template evaluation. It is recorded when the migration is applied and
+	// compared again on later runs to detect drift; see Migrator.Verify.
+	// It is empty for Go-code migrations.
+	Checksum string
+	// UpFn and DownFn are set instead of UpSQL/DownSQL for migrations
+	// registered in Go code with Register. MigrateTo calls them directly,
+	// inside the same advisory-locked transaction a SQL migration would
+	// have run in, rather than executing SQL.
+	UpFn, DownFn GoMigrationFunc
+}
+
+// IsGo reports whether this migration is a Go-code migration registered
+// with Register, rather than an on-disk SQL file.
+func (m *Migration) IsGo() bool {
+	return m.UpFn != nil || m.DownFn != nil
 }
 
 type MigratorOptions struct {
 	// DisableTx causes the Migrator not to run migrations in a transaction.
+	// Incompatible with Go migrations registered via Register/GoMigrations,
+	// which require a pgx.Tx to run against; MigrateTo returns an error if
+	// it reaches one with DisableTx set.
 	DisableTx bool
 	// MigratorFS is the interface used for collecting the migrations.
 	MigratorFS MigratorFS
+	// TimestampIDs causes migration file names to be parsed as
+	// <timestamp>_name.sql (e.g. 20240115093000_add_users.sql) rather than
+	// requiring a strict 1..N sequence. Migrations are sorted
+	// lexicographically by file name and applied in that order; use
+	// Migrator.Fix to renumber a directory of timestamped migrations into a
+	// contiguous sequence once a branch is merged. Unless AllowDrift is set,
+	// MigrateTo also checks applied_migrations against the current sort
+	// order and returns an OutOfSequenceMigrationError if a migration that
+	// should already be applied is missing, or one outside that set is
+	// applied - the scalar version counter alone can't detect migrations
+	// applied out of timestamp order on separate branches before a merge.
+	TimestampIDs bool
+	// AllowDrift skips the checksum verification MigrateTo otherwise runs
+	// before applying migrations, so a MigrationChecksumError from an
+	// already-applied migration having changed on disk does not block the
+	// run. Intended as an explicit escape hatch, not a default.
+	AllowDrift bool
+	// GoMigrations, if set, is merged with on-disk SQL migrations by
+	// LoadMigrations (see GoMigrationRegistry). Left nil, LoadMigrations
+	// behaves exactly as it would without Go migrations at all, regardless
+	// of what any other package may have registered with Register.
+	GoMigrations *GoMigrationRegistry
 }
 
 type Migrator struct {
-	conn         *pgx.Conn
+	driver       StorageDriver
 	versionTable string
 	options      *MigratorOptions
 	Migrations   []*Migration
 	OnStart      func(int32, string, string, string) // OnStart is called when a migration is run with the sequence, name, direction, and SQL
+	Hooks        Hooks                               // Hooks are called at various points of a MigrateTo run; see Hooks.
 	Data         map[string]interface{}              // Data available to use in migrations
 }
 
-// NewMigrator initializes a new Migrator. It is highly recommended that versionTable be schema qualified.
+// NewMigrator initializes a new Migrator targeting Postgres via conn. It is
+// highly recommended that versionTable be schema qualified.
 func NewMigrator(ctx context.Context, conn *pgx.Conn, versionTable string) (m *Migrator, err error) {
 	return NewMigratorEx(ctx, conn, versionTable, &MigratorOptions{MigratorFS: DefaultMigratorFS{}})
 }
 
-// NewMigratorEx initializes a new Migrator. It is highly recommended that versionTable be schema qualified.
+// NewMigratorEx initializes a new Migrator targeting Postgres via conn. It is
+// highly recommended that versionTable be schema qualified.
 func NewMigratorEx(ctx context.Context, conn *pgx.Conn, versionTable string, opts *MigratorOptions) (m *Migrator, err error) {
-	m = &Migrator{conn: conn, versionTable: versionTable, options: opts}
-	err = m.ensureSchemaVersionTableExists(ctx)
+	return NewMigratorFromDriver(ctx, NewPostgresDriver(conn), versionTable, opts)
+}
+
+// NewMigratorFromDriver initializes a new Migrator against an arbitrary
+// StorageDriver, allowing the same migration directory, templates, and
+// sprig tooling to target Postgres, MySQL, SQLite, ClickHouse, or any other
+// backend with a StorageDriver implementation. It is highly recommended
+// that versionTable be schema qualified where the backend supports it.
+func NewMigratorFromDriver(ctx context.Context, driver StorageDriver, versionTable string, opts *MigratorOptions) (m *Migrator, err error) {
+	m = &Migrator{driver: driver, versionTable: versionTable, options: opts}
+	err = m.driver.EnsureVersionTable(ctx, m.versionTable)
 	m.Migrations = make([]*Migration, 0)
 	m.Data = make(map[string]interface{})
 	return
@@ -195,7 +256,19 @@ func (m *Migrator) LoadMigrations(path string) error {
 		}
 	}
 
-	paths, err := FindMigrationsEx(path, m.options.MigratorFS)
+	if m.options.GoMigrations != nil {
+		if m.options.TimestampIDs {
+			return fmt.Errorf("migrate: GoMigrations is not supported with TimestampIDs")
+		}
+		return m.loadMigrationsWithGo(path, mainTmpl, m.options.GoMigrations)
+	}
+
+	find := FindMigrationsEx
+	if m.options.TimestampIDs {
+		find = FindTimestampedMigrationsEx
+	}
+
+	paths, err := find(path, m.options.MigratorFS)
 	if err != nil {
 		return err
 	}
@@ -205,47 +278,67 @@ func (m *Migrator) LoadMigrations(path string) error {
 	}
 
 	for _, p := range paths {
-		body, err := m.options.MigratorFS.ReadFile(p)
-		if err != nil {
-			return err
-		}
-
-		pieces := strings.SplitN(string(body), "---- create above / drop below ----", 2)
-		var upSQL, downSQL string
-		upSQL = strings.TrimSpace(pieces[0])
-		upSQL, err = m.evalMigration(mainTmpl.New(filepath.Base(p)+" up"), upSQL)
+		name, upSQL, downSQL, err := m.loadSQLMigrationFile(mainTmpl, p)
 		if err != nil {
 			return err
 		}
-		// Make sure there is SQL in the forward migration step.
-		containsSQL := false
-		for _, v := range strings.Split(upSQL, "\n") {
-			// Only account for regular single line comment, empty line and space/comment combination
-			cleanString := strings.TrimSpace(v)
-			if len(cleanString) != 0 &&
-				!strings.HasPrefix(cleanString, "--") {
-				containsSQL = true
-				break
-			}
-		}
-		if !containsSQL {
-			return ErrNoFwMigration
-		}
 
-		if len(pieces) == 2 {
-			downSQL = strings.TrimSpace(pieces[1])
-			downSQL, err = m.evalMigration(mainTmpl.New(filepath.Base(p)+" down"), downSQL)
+		if m.options.TimestampIDs {
+			id, err := parseTimestampID(name)
 			if err != nil {
 				return err
 			}
+			m.appendMigration(name, id, upSQL, downSQL)
+		} else {
+			m.AppendMigration(name, upSQL, downSQL)
 		}
-
-		m.AppendMigration(filepath.Base(p), upSQL, downSQL)
 	}
 
 	return nil
 }
 
+// loadSQLMigrationFile reads and template-evaluates a single SQL migration
+// file at p, splitting it into its up and down halves.
+func (m *Migrator) loadSQLMigrationFile(mainTmpl *template.Template, p string) (name, upSQL, downSQL string, err error) {
+	body, err := m.options.MigratorFS.ReadFile(p)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	name = filepath.Base(p)
+
+	pieces := strings.SplitN(string(body), "---- create above / drop below ----", 2)
+	upSQL = strings.TrimSpace(pieces[0])
+	upSQL, err = m.evalMigration(mainTmpl.New(name+" up"), upSQL)
+	if err != nil {
+		return "", "", "", err
+	}
+	// Make sure there is SQL in the forward migration step.
+	containsSQL := false
+	for _, v := range strings.Split(upSQL, "\n") {
+		// Only account for regular single line comment, empty line and space/comment combination
+		cleanString := strings.TrimSpace(v)
+		if len(cleanString) != 0 &&
+			!strings.HasPrefix(cleanString, "--") {
+			containsSQL = true
+			break
+		}
+	}
+	if !containsSQL {
+		return "", "", "", ErrNoFwMigration
+	}
+
+	if len(pieces) == 2 {
+		downSQL = strings.TrimSpace(pieces[1])
+		downSQL, err = m.evalMigration(mainTmpl.New(name+" down"), downSQL)
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+
+	return name, upSQL, downSQL, nil
+}
+
 func (m *Migrator) evalMigration(tmpl *template.Template, sql string) (string, error) {
 	tmpl, err := tmpl.Parse(sql)
 	if err != nil {
@@ -262,44 +355,49 @@ func (m *Migrator) evalMigration(tmpl *template.Template, sql string) (string, e
 }
 
 func (m *Migrator) AppendMigration(name, upSQL, downSQL string) {
+	m.appendMigration(name, int64(len(m.Migrations))+1, upSQL, downSQL)
+}
+
+func (m *Migrator) appendMigration(name string, id int64, upSQL, downSQL string) {
 	m.Migrations = append(
 		m.Migrations,
 		&Migration{
 			Sequence: int32(len(m.Migrations)) + 1,
+			ID:       id,
 			Name:     name,
 			UpSQL:    upSQL,
 			DownSQL:  downSQL,
+			Checksum: migrationChecksum(upSQL),
 		})
 	return
 }
 
+func (m *Migrator) appendGoMigration(name string, up, down GoMigrationFunc) {
+	m.Migrations = append(
+		m.Migrations,
+		&Migration{
+			Sequence: int32(len(m.Migrations)) + 1,
+			ID:       int64(len(m.Migrations)) + 1,
+			Name:     name,
+			UpFn:     up,
+			DownFn:   down,
+		})
+}
+
 // Migrate runs pending migrations
 // It calls m.OnStart when it begins a migration
 func (m *Migrator) Migrate(ctx context.Context) error {
 	return m.MigrateTo(ctx, int32(len(m.Migrations)))
 }
 
-// Lock to ensure multiple migrations cannot occur simultaneously
-const lockNum = int64(9628173550095224) // arbitrary random number
-
-func acquireAdvisoryLock(ctx context.Context, conn *pgx.Conn) error {
-	_, err := conn.Exec(ctx, "select pg_advisory_lock($1)", lockNum)
-	return err
-}
-
-func releaseAdvisoryLock(ctx context.Context, conn *pgx.Conn) error {
-	_, err := conn.Exec(ctx, "select pg_advisory_unlock($1)", lockNum)
-	return err
-}
-
 // MigrateTo migrates to targetVersion
 func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int32) (err error) {
-	err = acquireAdvisoryLock(ctx, m.conn)
+	err = m.driver.Lock(ctx)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		unlockErr := releaseAdvisoryLock(ctx, m.conn)
+		unlockErr := m.driver.Unlock(ctx)
 		if err == nil && unlockErr != nil {
 			err = unlockErr
 		}
@@ -310,14 +408,21 @@ func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int32) (err erro
 		return err
 	}
 
-	if targetVersion < 0 || int32(len(m.Migrations)) < targetVersion {
-		errMsg := fmt.Sprintf("destination version %d is outside the valid versions of 0 to %d", targetVersion, len(m.Migrations))
-		return BadVersionError(errMsg)
+	if !m.options.AllowDrift {
+		if err = m.Verify(ctx); err != nil && !errors.Is(err, ErrChecksumVerificationUnsupported) {
+			return err
+		}
+		err = nil
+
+		if m.options.TimestampIDs {
+			if err = m.checkTimestampSequence(ctx, currentVersion); err != nil {
+				return err
+			}
+		}
 	}
 
-	if currentVersion < 0 || int32(len(m.Migrations)) < currentVersion {
-		errMsg := fmt.Sprintf("current version %d is outside the valid versions of 0 to %d", currentVersion, len(m.Migrations))
-		return BadVersionError(errMsg)
+	if err = m.validateVersionRange(currentVersion, targetVersion); err != nil {
+		return err
 	}
 
 	var direction int32
@@ -327,6 +432,12 @@ func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int32) (err erro
 		direction = -1
 	}
 
+	if m.Hooks.BeforeAll != nil {
+		if err = m.Hooks.BeforeAll(ctx); err != nil {
+			return err
+		}
+	}
+
 	for currentVersion != targetVersion {
 		var current *Migration
 		var sql, directionName string
@@ -341,94 +452,98 @@ func (m *Migrator) MigrateTo(ctx context.Context, targetVersion int32) (err erro
 			sequence = current.Sequence - 1
 			sql = current.DownSQL
 			directionName = "down"
-			if current.DownSQL == "" {
+			if current.DownSQL == "" && current.DownFn == nil {
 				return IrreversibleMigrationError{m: current}
 			}
 		}
+		dir := Direction(directionName)
 
-		var tx pgx.Tx
+		var tx DriverTx
 		if !m.options.DisableTx {
-			tx, err = m.conn.Begin(ctx)
+			tx, err = m.driver.BeginTx(ctx)
 			if err != nil {
 				return err
 			}
 			defer tx.Rollback(ctx)
 		}
 
+		if m.Hooks.BeforeMigration != nil {
+			if err = m.Hooks.BeforeMigration(ctx, current, dir); err != nil {
+				return m.fireOnError(ctx, current, err)
+			}
+		}
+
 		// Fire on start callback
 		if m.OnStart != nil {
 			m.OnStart(current.Sequence, current.Name, directionName, sql)
 		}
 
+		start := time.Now()
+
 		// Execute the migration
-		_, err = m.conn.Exec(ctx, sql)
+		if current.IsGo() {
+			err = m.execGoMigration(ctx, tx, current, direction == 1)
+		} else {
+			err = m.driver.Exec(ctx, tx, current.Name, sql)
+		}
 		if err != nil {
-			if err, ok := err.(*pgconn.PgError); ok {
-				return MigrationPgError{MigrationName: current.Name, Sql: sql, PgError: err}
-			}
-			return err
+			return m.fireOnError(ctx, current, err)
 		}
 
-		// Reset all database connection settings. Important to do before updating version as search_path may have been changed.
-		m.conn.Exec(ctx, "reset all")
-
 		// Add one to the version
-		_, err = m.conn.Exec(ctx, "update "+m.versionTable+" set version=$1", sequence)
+		err = m.driver.SetVersion(ctx, tx, m.versionTable, sequence)
 		if err != nil {
-			return err
+			return m.fireOnError(ctx, current, err)
+		}
+
+		if recorder, ok := m.driver.(AppliedMigrationsRecorder); ok {
+			if direction == 1 {
+				err = recorder.RecordAppliedMigration(ctx, tx, m.versionTable, current.ID, current.Name, current.Checksum)
+			} else {
+				// The migration being reverted is no longer part of the
+				// current schema; drop its row so a later edit to the
+				// now-unapplied file can't be mistaken for drift.
+				err = recorder.RemoveAppliedMigration(ctx, tx, m.versionTable, current.ID)
+			}
+			if err != nil {
+				return m.fireOnError(ctx, current, err)
+			}
 		}
 
 		if !m.options.DisableTx {
 			err = tx.Commit(ctx)
 			if err != nil {
-				return err
+				return m.fireOnError(ctx, current, err)
+			}
+		}
+
+		if m.Hooks.AfterMigration != nil {
+			if err = m.Hooks.AfterMigration(ctx, current, dir, time.Since(start)); err != nil {
+				return m.fireOnError(ctx, current, err)
 			}
 		}
 
 		currentVersion = currentVersion + direction
 	}
 
-	return nil
-}
-
-func (m *Migrator) GetCurrentVersion(ctx context.Context) (v int32, err error) {
-	err = m.conn.QueryRow(ctx, "select version from "+m.versionTable).Scan(&v)
-	return v, err
-}
-
-func (m *Migrator) ensureSchemaVersionTableExists(ctx context.Context) (err error) {
-	err = acquireAdvisoryLock(ctx, m.conn)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		unlockErr := releaseAdvisoryLock(ctx, m.conn)
-		if err == nil && unlockErr != nil {
-			err = unlockErr
+	if m.Hooks.AfterAll != nil {
+		if err = m.Hooks.AfterAll(ctx); err != nil {
+			return err
 		}
-	}()
-
-	if ok, err := m.versionTableExists(ctx); err != nil || ok {
-		return err
 	}
 
-	_, err = m.conn.Exec(ctx, fmt.Sprintf(`
-    create table if not exists %s(version int4 not null);
+	return nil
+}
 
-    insert into %s(version)
-    select 0
-    where 0=(select count(*) from %s);
-  `, m.versionTable, m.versionTable, m.versionTable))
+// fireOnError invokes m.Hooks.OnError, if set, and returns err unchanged so
+// callers can write "return m.fireOnError(ctx, current, err)".
+func (m *Migrator) fireOnError(ctx context.Context, mig *Migration, err error) error {
+	if m.Hooks.OnError != nil {
+		m.Hooks.OnError(ctx, mig, err)
+	}
 	return err
 }
 
-func (m *Migrator) versionTableExists(ctx context.Context) (ok bool, err error) {
-	var count int
-	if i := strings.IndexByte(m.versionTable, '.'); i == -1 {
-		err = m.conn.QueryRow(ctx, "select count(*) from pg_catalog.pg_class where relname=$1 and relkind='r' and pg_table_is_visible(oid)", m.versionTable).Scan(&count)
-	} else {
-		schema, table := m.versionTable[:i], m.versionTable[i+1:]
-		err = m.conn.QueryRow(ctx, "select count(*) from pg_catalog.pg_tables where schemaname=$1 and tablename=$2", schema, table).Scan(&count)
-	}
-	return count > 0, err
+func (m *Migrator) GetCurrentVersion(ctx context.Context) (v int32, err error) {
+	return m.driver.GetCurrentVersion(ctx, m.versionTable)
 }
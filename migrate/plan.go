@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// PlannedStep describes one migration MigrateTo would apply or revert, as
+// computed by Migrator.Plan.
+type PlannedStep struct {
+	Migration *Migration
+	Direction Direction
+	// SQL is the fully template-evaluated SQL this step would execute. It
+	// is empty for Go-code migrations; check Migration.IsGo() instead.
+	SQL string
+}
+
+// validateVersionRange checks that current and target are both within
+// 0..len(m.Migrations), the same check MigrateTo performs before applying
+// anything.
+func (m *Migrator) validateVersionRange(currentVersion, targetVersion int32) error {
+	if targetVersion < 0 || int32(len(m.Migrations)) < targetVersion {
+		return BadVersionError(fmt.Sprintf("destination version %d is outside the valid versions of 0 to %d", targetVersion, len(m.Migrations)))
+	}
+
+	if currentVersion < 0 || int32(len(m.Migrations)) < currentVersion {
+		return BadVersionError(fmt.Sprintf("current version %d is outside the valid versions of 0 to %d", currentVersion, len(m.Migrations)))
+	}
+
+	return nil
+}
+
+// Plan returns the ordered sequence of migrations MigrateTo would apply (or
+// revert) to reach targetVersion, along with their direction and fully
+// rendered SQL, without acquiring the advisory lock or touching the
+// database version row. It is the basis for a "tern migrate --dry-run"
+// style workflow, or for a CI pipeline that wants to diff planned SQL
+// against a schema snapshot before merging.
+func (m *Migrator) Plan(ctx context.Context, targetVersion int32) ([]PlannedStep, error) {
+	currentVersion, err := m.GetCurrentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.validateVersionRange(currentVersion, targetVersion); err != nil {
+		return nil, err
+	}
+
+	var direction int32
+	if currentVersion < targetVersion {
+		direction = 1
+	} else {
+		direction = -1
+	}
+
+	var steps []PlannedStep
+	for v := currentVersion; v != targetVersion; v += direction {
+		var current *Migration
+		var sql string
+		var dir Direction
+		if direction == 1 {
+			current = m.Migrations[v]
+			sql = current.UpSQL
+			dir = Up
+		} else {
+			current = m.Migrations[v-1]
+			sql = current.DownSQL
+			dir = Down
+			if current.DownSQL == "" && current.DownFn == nil {
+				return nil, IrreversibleMigrationError{m: current}
+			}
+		}
+
+		steps = append(steps, PlannedStep{Migration: current, Direction: dir, SQL: sql})
+	}
+
+	return steps, nil
+}
@@ -0,0 +1,23 @@
+package migrate
+
+import "testing"
+
+func TestValidateVersionRange(t *testing.T) {
+	m := &Migrator{Migrations: make([]*Migration, 3)}
+
+	if err := m.validateVersionRange(0, 3); err != nil {
+		t.Fatalf("unexpected error for in-range versions: %v", err)
+	}
+
+	if err := m.validateVersionRange(0, 4); err == nil {
+		t.Fatal("expected an error for a target version beyond the loaded migrations")
+	}
+
+	if err := m.validateVersionRange(-1, 2); err == nil {
+		t.Fatal("expected an error for a negative current version")
+	}
+
+	if err := m.validateVersionRange(4, 2); err == nil {
+		t.Fatal("expected an error for a current version beyond the loaded migrations")
+	}
+}
@@ -0,0 +1,143 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// timestampMigrationPattern matches TimestampIDs migration file names such
+// as 20240115093000_add_users.sql.
+var timestampMigrationPattern = regexp.MustCompile(`\A(\d+)_.+\.sql\z`)
+
+// FindTimestampedMigrationsEx finds migrations under path named
+// <timestamp>_name.sql and returns them sorted lexicographically by file
+// name (which, for fixed-width timestamps, is also chronological order).
+// Unlike FindMigrationsEx it does not require a contiguous 1..N sequence,
+// so migrations authored on concurrent feature branches do not collide.
+func FindTimestampedMigrationsEx(path string, fs MigratorFS) ([]string, error) {
+	path = strings.TrimRight(path, string(filepath.Separator))
+
+	fileInfos, err := fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool)
+	names := make([]string, 0, len(fileInfos))
+	for _, fi := range fileInfos {
+		if fi.IsDir() {
+			continue
+		}
+
+		if !timestampMigrationPattern.MatchString(fi.Name()) {
+			continue
+		}
+
+		id, err := parseTimestampID(fi.Name())
+		if err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("Duplicate migration %d", id)
+		}
+		seen[id] = true
+
+		names = append(names, fi.Name())
+	}
+
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(path, name)
+	}
+
+	return paths, nil
+}
+
+// FindTimestampedMigrations finds timestamped migrations on the default
+// filesystem. See FindTimestampedMigrationsEx.
+func FindTimestampedMigrations(path string) ([]string, error) {
+	return FindTimestampedMigrationsEx(path, DefaultMigratorFS{})
+}
+
+// parseTimestampID extracts the numeric ID prefix from a timestamped
+// migration file name.
+func parseTimestampID(name string) (int64, error) {
+	matches := timestampMigrationPattern.FindStringSubmatch(name)
+	if len(matches) != 2 {
+		return 0, BadVersionError("not a timestamped migration: " + name)
+	}
+	return strconv.ParseInt(matches[1], 10, 64)
+}
+
+// OutOfSequenceMigrationError is returned by Migrator.MigrateTo in
+// TimestampIDs mode when applied_migrations does not hold exactly the IDs
+// of the first currentVersion migrations in Migrations' current (merged,
+// sorted-by-timestamp) order. This is what happens when migrations are
+// applied out of timestamp order on separate branches and then merged -
+// e.g. T2 is applied while it is sequence 1, then branch merge inserts T1
+// ahead of it in sort order - and is exactly what the scalar version
+// counter alone cannot detect: it would otherwise re-apply T2 while
+// silently skipping T1.
+type OutOfSequenceMigrationError struct {
+	// Missing holds the IDs of migrations that should already be applied
+	// (the first currentVersion migrations, by current sort order) but
+	// are absent from applied_migrations.
+	Missing []int64
+	// Unexpected holds the IDs of applied migrations outside that expected
+	// set, most often a migration applied before one that later sorted
+	// ahead of it.
+	Unexpected []int64
+}
+
+func (e OutOfSequenceMigrationError) Error() string {
+	return fmt.Sprintf("migrate: applied migrations are out of sequence (missing %v, unexpected %v) - reconcile applied_migrations, then re-run Fix", e.Missing, e.Unexpected)
+}
+
+// checkTimestampSequence compares the IDs recorded in applied_migrations
+// against the first currentVersion migrations in m.Migrations, returning an
+// OutOfSequenceMigrationError if they disagree. It is a no-op if the driver
+// does not implement ChecksumVerifier, since there is then no
+// applied_migrations log to compare against.
+func (m *Migrator) checkTimestampSequence(ctx context.Context, currentVersion int32) error {
+	verifier, ok := m.driver.(ChecksumVerifier)
+	if !ok {
+		return nil
+	}
+
+	applied, err := verifier.GetAppliedChecksums(ctx, m.versionTable)
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[int64]bool, currentVersion)
+	for i := int32(0); i < currentVersion; i++ {
+		expected[m.Migrations[i].ID] = true
+	}
+
+	var missing, unexpected []int64
+	for id := range expected {
+		if _, ok := applied[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	for id := range applied {
+		if !expected[id] {
+			unexpected = append(unexpected, id)
+		}
+	}
+
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return nil
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+	sort.Slice(unexpected, func(i, j int) bool { return unexpected[i] < unexpected[j] })
+	return OutOfSequenceMigrationError{Missing: missing, Unexpected: unexpected}
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeFileInfo is a minimal os.FileInfo for exercising MigratorFS consumers
+// without touching a real filesystem.
+type fakeFileInfo struct {
+	name  string
+	isDir bool
+}
+
+func (fi fakeFileInfo) Name() string       { return fi.name }
+func (fi fakeFileInfo) Size() int64        { return 0 }
+func (fi fakeFileInfo) Mode() os.FileMode  { return 0 }
+func (fi fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fakeFileInfo) IsDir() bool        { return fi.isDir }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
+
+// fakeMigratorFS is an in-memory MigratorFS backed by a single directory
+// listing, enough to drive the file-discovery helpers in this package.
+type fakeMigratorFS struct {
+	entries []os.FileInfo
+}
+
+func (f fakeMigratorFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return f.entries, nil
+}
+
+func (f fakeMigratorFS) ReadFile(filename string) ([]byte, error) {
+	return nil, os.ErrNotExist
+}
+
+func (f fakeMigratorFS) Glob(pattern string) ([]string, error) {
+	return nil, nil
+}
+
+func TestFindTimestampedMigrationsEx(t *testing.T) {
+	fs := fakeMigratorFS{entries: []os.FileInfo{
+		fakeFileInfo{name: "20240115093000_add_users.sql"},
+		fakeFileInfo{name: "20240101000000_create_widgets.sql"},
+		fakeFileInfo{name: "not_a_migration.txt"},
+		fakeFileInfo{name: "20240201000000_subdir", isDir: true},
+	}}
+
+	paths, err := FindTimestampedMigrationsEx("migrations", fs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"migrations/20240101000000_create_widgets.sql",
+		"migrations/20240115093000_add_users.sql",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("got %v, want %v", paths, want)
+		}
+	}
+}
+
+func TestFindTimestampedMigrationsExDuplicate(t *testing.T) {
+	fs := fakeMigratorFS{entries: []os.FileInfo{
+		fakeFileInfo{name: "20240115093000_add_users.sql"},
+		fakeFileInfo{name: "20240115093000_add_widgets.sql"},
+	}}
+
+	_, err := FindTimestampedMigrationsEx("migrations", fs)
+	if err == nil {
+		t.Fatal("expected an error for two migrations sharing a timestamp ID")
+	}
+}
+
+func TestParseTimestampID(t *testing.T) {
+	id, err := parseTimestampID("20240115093000_add_users.sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != 20240115093000 {
+		t.Fatalf("got %d, want 20240115093000", id)
+	}
+
+	if _, err := parseTimestampID("not_timestamped.sql"); err == nil {
+		t.Fatal("expected an error for a non-timestamped name")
+	}
+}
+
+// fakeChecksumDriver is a no-op StorageDriver that additionally implements
+// ChecksumVerifier from a fixed map, enough to drive
+// Migrator.checkTimestampSequence without a real database.
+type fakeChecksumDriver struct {
+	checksums map[int64]string
+}
+
+func (d fakeChecksumDriver) EnsureVersionTable(ctx context.Context, versionTable string) error {
+	return nil
+}
+func (d fakeChecksumDriver) GetCurrentVersion(ctx context.Context, versionTable string) (int32, error) {
+	return 0, nil
+}
+func (d fakeChecksumDriver) SetVersion(ctx context.Context, tx DriverTx, versionTable string, version int32) error {
+	return nil
+}
+func (d fakeChecksumDriver) Lock(ctx context.Context) error   { return nil }
+func (d fakeChecksumDriver) Unlock(ctx context.Context) error { return nil }
+func (d fakeChecksumDriver) BeginTx(ctx context.Context) (DriverTx, error) {
+	return nil, nil
+}
+func (d fakeChecksumDriver) Exec(ctx context.Context, tx DriverTx, name, sql string) error {
+	return nil
+}
+func (d fakeChecksumDriver) GetAppliedChecksums(ctx context.Context, versionTable string) (map[int64]string, error) {
+	return d.checksums, nil
+}
+
+func TestCheckTimestampSequence(t *testing.T) {
+	m := &Migrator{
+		driver: fakeChecksumDriver{checksums: map[int64]string{1: "a"}},
+		Migrations: []*Migration{
+			{ID: 1, Checksum: "a"},
+			{ID: 2, Checksum: "b"},
+		},
+	}
+
+	if err := m.checkTimestampSequence(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error for an in-sequence applied set: %v", err)
+	}
+
+	// T2 applied while it was sequence 1; branch merge later inserts T1
+	// ahead of it, so the first currentVersion=1 migration (T1) is missing
+	// from applied_migrations while T2, now outside that prefix, isn't.
+	m.driver = fakeChecksumDriver{checksums: map[int64]string{2: "b"}}
+	err := m.checkTimestampSequence(context.Background(), 1)
+	if err == nil {
+		t.Fatal("expected an error for an out-of-sequence applied set")
+	}
+	oose, ok := err.(OutOfSequenceMigrationError)
+	if !ok {
+		t.Fatalf("got error of type %T, want OutOfSequenceMigrationError", err)
+	}
+	if len(oose.Missing) != 1 || oose.Missing[0] != 1 {
+		t.Fatalf("got Missing %v, want [1]", oose.Missing)
+	}
+	if len(oose.Unexpected) != 1 || oose.Unexpected[0] != 2 {
+		t.Fatalf("got Unexpected %v, want [2]", oose.Unexpected)
+	}
+}